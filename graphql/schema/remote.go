@@ -17,12 +17,11 @@
 package schema
 
 import (
-	"bytes"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net/http"
-	"time"
+	"sync"
 
 	"github.com/dgraph-io/dgraph/x"
 	"github.com/pkg/errors"
@@ -33,36 +32,57 @@ import (
 // TypeKey uses restricted delimiter to form the key.
 var returnType = string(x.TypeKey("graphql-return"))
 
-// introspectRemoteSchema introspectes remote schema
-func introspectRemoteSchema(url string) (*IntrospectedSchema, error) {
+// introspectRemoteSchema introspects the remote schema at url, serving a
+// cached result when one is still fresh and otherwise fetching from the
+// remote using endpointConfig's auth/timeout settings. Concurrent
+// introspections of the same url are coalesced so that only one request hits
+// the wire at a time.
+func introspectRemoteSchema(url string, endpointConfig *RemoteEndpointConfig) (*IntrospectedSchema, error) {
+	return defaultIntrospectionCache.resolveWithCache(url, func(etag string) (*IntrospectedSchema, string, error) {
+		return fetchRemoteSchema(url, etag, endpointConfig)
+	})
+}
+
+// fetchRemoteSchema unconditionally fetches and parses the introspection
+// result from url, using endpointConfig for auth headers and timeout. If etag
+// is non-empty it is sent as If-None-Match; a 304 response is reported back
+// as a nil schema so the caller can keep serving its cached copy.
+func fetchRemoteSchema(url, etag string, endpointConfig *RemoteEndpointConfig) (*IntrospectedSchema, string, error) {
 	param := &Request{
 		Query: introspectionQuery,
 	}
 
 	body, err := json.Marshal(param)
-
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 
-	req, err := http.NewRequest(http.MethodPost, url, bytes.NewBuffer(body))
+	req, client, err := newRemoteRequest(url, body, nil, endpointConfig)
 	if err != nil {
-		return nil, err
+		return nil, "", err
+	}
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
 	}
-	req.Header.Set("Content-Type", "application/json")
-	client := &http.Client{Timeout: 5 * time.Second}
 	resp, err := client.Do(req)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified {
+		return nil, etag, nil
+	}
+
 	body, err = ioutil.ReadAll(resp.Body)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 	result := &IntrospectedSchema{}
-
-	return result, json.Unmarshal(body, result)
+	if err := json.Unmarshal(body, result); err != nil {
+		return nil, "", err
+	}
+	return result, resp.Header.Get("ETag"), nil
 }
 
 const introspectionQuery = `
@@ -165,15 +185,38 @@ type remoteGraphqlMetadata struct {
 	url string
 	// schema is the parsed schema given by the user
 	schema *ast.Schema
+	// customization, if present, describes how the remote schema's root
+	// fields, type names and field names are renamed into the local naming
+	// space. It is parsed out of the customization block alongside url.
+	customization *RemoteGraphqlCustomization
+	// fieldResolution is populated during validation when @custom is applied
+	// to a field of a non-root type; it records how that field should be
+	// resolved at query time.
+	fieldResolution *fieldResolutionMetadata
+	// endpointConfig carries the auth/header/timeout configuration for url,
+	// so that introspection uses the same auth as runtime calls.
+	endpointConfig *RemoteEndpointConfig
 }
 
-// validates the graphql given in @custom->http->graphql by introspecting remote schema.
-// It assumes that the graphql syntax is correct, only remote validation is needed.
-func validateRemoteGraphql(metadata *remoteGraphqlMetadata) error {
-	remoteIntrospection, err := introspectRemoteSchema(metadata.url)
+// validates the graphql given in @custom->http->graphql by introspecting remote schema. It
+// assumes that the graphql syntax is correct, only remote validation is needed. When @custom is
+// applied to a field of a non-root type, the returned *fieldResolutionMetadata records how that
+// field must be resolved at query time (per-item vs batch, via ResolveField); callers validating
+// such a field must hold onto it, since validation is the only place that mode is determined.
+func validateRemoteGraphql(metadata *remoteGraphqlMetadata) (*fieldResolutionMetadata, error) {
+	if err := metadata.customization.Validate(metadata.schema); err != nil {
+		return nil, err
+	}
+
+	remoteIntrospection, err := introspectRemoteSchema(metadata.url, metadata.endpointConfig)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	// Rewrite the introspected type/field names into the local naming space
+	// before comparing against the local schema, so that expectedReturnType,
+	// remoteQryArgDefs and expandedRemoteTypes below line up with what the
+	// local schema declares.
+	remoteIntrospection = metadata.customization.localizeIntrospection(remoteIntrospection)
 
 	var remoteQueryTypename string
 	operationType := metadata.graphqlOpDef.Operation
@@ -185,29 +228,15 @@ func validateRemoteGraphql(metadata *remoteGraphqlMetadata) error {
 	default:
 		// this case is not possible as we are validating the operation to be query/mutation in
 		// @custom directive validation
-		return errors.Errorf("found %s operation, it can only have query/mutation.", operationType)
+		return nil, errors.Errorf("found %s operation, it can only have query/mutation.", operationType)
 	}
 
-	var introspectedRemoteQuery *GqlField
 	givenQuery := metadata.graphqlOpDef.SelectionSet[0].(*ast.Field)
-	for _, typ := range remoteIntrospection.Data.Schema.Types {
-		if typ.Name != remoteQueryTypename {
-			continue
-		}
-		for _, remoteQuery := range typ.Fields {
-			if remoteQuery.Name == givenQuery.Name {
-				introspectedRemoteQuery = &remoteQuery
-				break
-			}
-		}
-		if introspectedRemoteQuery != nil {
-			break
-		}
-	}
+	introspectedRemoteQuery, _ := remoteIntrospection.fieldByName(remoteQueryTypename, givenQuery.Name)
 
 	// check whether given query/mutation is present in remote schema
 	if introspectedRemoteQuery == nil {
-		return errors.Errorf("given %s: %s is not present in remote schema.",
+		return nil, errors.Errorf("given %s: %s is not present in remote schema.",
 			operationType, givenQuery.Name)
 	}
 
@@ -216,29 +245,39 @@ func validateRemoteGraphql(metadata *remoteGraphqlMetadata) error {
 	expectedReturnType := introspectedRemoteQuery.Type.String()
 	gotReturnType := metadata.parentField.Type.String()
 	if expectedReturnType != gotReturnType {
-		return errors.Errorf("given %s: %s: return type mismatch; expected: %s, got: %s.",
+		return nil, errors.Errorf("given %s: %s: return type mismatch; expected: %s, got: %s.",
 			operationType, givenQuery.Name, expectedReturnType, gotReturnType)
 	}
 
-	givenQryArgDefs, givenQryArgVals := getGivenQueryArgsAsMap(givenQuery, metadata.parentField,
-		metadata.parentType)
+	givenQryArgDefs, givenQryArgVals, dependentFields, err := getGivenQueryArgsAsMap(givenQuery,
+		metadata.parentField, metadata.parentType)
+	if err != nil {
+		return nil, err
+	}
 	remoteQryArgDefs, remoteQryRequiredArgs := getRemoteQueryArgsAsMap(introspectedRemoteQuery)
 
+	if metadata.parentType.Name != "Query" && metadata.parentType.Name != "Mutation" {
+		metadata.fieldResolution = &fieldResolutionMetadata{dependentFields: dependentFields}
+		if err := validateFieldResolutionMode(metadata, introspectedRemoteQuery); err != nil {
+			return nil, err
+		}
+	}
+
 	// check whether args of given query/mutation match the args of remote query/mutation
 	for givenArgName, givenArgDef := range givenQryArgDefs {
 		remoteArgDef, ok := remoteQryArgDefs[givenArgName]
 		if !ok {
-			return errors.Errorf("given %s: %s: arg %s not present in remote %s.", operationType,
+			return nil, errors.Errorf("given %s: %s: arg %s not present in remote %s.", operationType,
 				givenQuery.Name, givenArgName, operationType)
 		}
 		if givenArgDef == nil {
-			return errors.Errorf("given %s: %s: variable %s is missing in given context.",
+			return nil, errors.Errorf("given %s: %s: variable %s is missing in given context.",
 				operationType, givenQuery.Name, givenQryArgVals[givenArgName])
 		}
 		expectedArgType := remoteArgDef.Type.String()
 		gotArgType := givenArgDef.Type.String()
 		if expectedArgType != gotArgType {
-			return errors.Errorf("given %s: %s: type mismatch for variable %s; expected: %s, "+
+			return nil, errors.Errorf("given %s: %s: type mismatch for variable %s; expected: %s, "+
 				"got: %s.", operationType, givenQuery.Name, givenQryArgVals[givenArgName],
 				expectedArgType, gotArgType)
 		}
@@ -248,23 +287,31 @@ func validateRemoteGraphql(metadata *remoteGraphqlMetadata) error {
 	for _, remoteArgName := range remoteQryRequiredArgs {
 		_, ok := givenQryArgVals[remoteArgName]
 		if !ok {
-			return errors.Errorf("given %s: %s: required arg %s is missing.", operationType,
+			return nil, errors.Errorf("given %s: %s: required arg %s is missing.", operationType,
 				givenQuery.Name, remoteArgName)
 		}
 	}
 
+	// Walk the given operation's selection set to confirm that every
+	// requested subfield, inline fragment and fragment spread actually
+	// exists on the remote type, including on unions/interfaces.
+	if err := validateSelectionSet(string(operationType)+"."+givenQuery.Name,
+		introspectedRemoteQuery.Type.NamedType(), givenQuery.SelectionSet, remoteIntrospection); err != nil {
+		return nil, err
+	}
+
 	// Add the return type to the remoteQryArgDefs to further expand the nested
 	// types to validate against the local schema.
 	remoteQryArgDefs[returnType] = Args{Type: introspectedRemoteQuery.Type}
 	expandedRemoteTypes, err := expandArgs(remoteQryArgDefs, remoteIntrospection)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	// Type check the expanded local type with the local schema.
 	for typeName, fields := range expandedRemoteTypes {
 		localType, ok := metadata.schema.Types[typeName]
 		if !ok {
-			return errors.Errorf(
+			return nil, errors.Errorf(
 				"Unable to find remote type %s in the local schema",
 				typeName,
 			)
@@ -272,13 +319,13 @@ func validateRemoteGraphql(metadata *remoteGraphqlMetadata) error {
 		for _, field := range fields {
 			localField := localType.Fields.ForName(field.Name)
 			if localField == nil {
-				return errors.Errorf(
+				return nil, errors.Errorf(
 					"%s field for the remote type %s is not present in the local type %s",
 					field.Name, localType.Name, localType.Name,
 				)
 			}
 			if localField.Type.String() != field.Type.String() {
-				return errors.Errorf(
+				return nil, errors.Errorf(
 					"expected type for the field %s is %s but got %s in type %s",
 					field.Name,
 					field.Type.String(),
@@ -289,7 +336,7 @@ func validateRemoteGraphql(metadata *remoteGraphqlMetadata) error {
 		}
 	}
 
-	return nil
+	return metadata.fieldResolution, nil
 }
 
 type expandArgParams struct {
@@ -305,33 +352,30 @@ func expandArgRecursively(arg string, param *expandArgParams) error {
 	}
 	// We're marking this to avoid recursive expansion.
 	param.expandedTypes[arg] = struct{}{}
-	typeFound := false
-	for _, inputType := range param.introspectedSchema.Data.Schema.Types {
-		if inputType.Name == arg {
-			typeFound = true
-			param.typesToFields[inputType.Name] = inputType.Fields
-			// Expand the non scalar types.
-			for _, field := range inputType.Fields {
-				_, ok := graphqlScalarType[field.Type.Name]
-				if !ok {
-					// expand this field.
-					err := expandArgRecursively(field.Type.NamedType(), param)
-					if err != nil {
-						return err
-					}
+	inputType, typeFound := param.introspectedSchema.typeByName(arg)
+	if typeFound {
+		param.typesToFields[inputType.Name] = inputType.Fields
+		// Expand the non scalar types.
+		for _, field := range inputType.Fields {
+			_, ok := graphqlScalarType[field.Type.Name]
+			if !ok {
+				// expand this field.
+				err := expandArgRecursively(field.Type.NamedType(), param)
+				if err != nil {
+					return err
 				}
 			}
-			// expand input fields as well.
-			param.typesToFields[inputType.Name] = append(param.typesToFields[inputType.Name],
-				inputType.InputFields...)
-			for _, field := range inputType.InputFields {
-				_, ok := graphqlScalarType[field.Type.NamedType()]
-				if !ok {
-					// expand this field.
-					err := expandArgRecursively(field.Type.NamedType(), param)
-					if err != nil {
-						return err
-					}
+		}
+		// expand input fields as well.
+		param.typesToFields[inputType.Name] = append(param.typesToFields[inputType.Name],
+			inputType.InputFields...)
+		for _, field := range inputType.InputFields {
+			_, ok := graphqlScalarType[field.Type.NamedType()]
+			if !ok {
+				// expand this field.
+				err := expandArgRecursively(field.Type.NamedType(), param)
+				if err != nil {
+					return err
 				}
 			}
 		}
@@ -370,10 +414,15 @@ func expandArgs(argToVal map[string]Args,
 // getGivenQueryArgsAsMap returns following maps:
 // 1. arg name -> *ast.ArgumentDefinition
 // 2. arg name -> argument value (i.e., variable like $id)
+// and the list of sibling fields on parentType that must be fetched to
+// satisfy the remote call, for @custom applied to a non-root field. It
+// returns an error if a ${parent.field} style reference doesn't resolve to an
+// actual sibling field of parentType.
 func getGivenQueryArgsAsMap(givenQuery *ast.Field, parentField *ast.FieldDefinition,
-	parentType *ast.Definition) (map[string]*ast.ArgumentDefinition, map[string]string) {
+	parentType *ast.Definition) (map[string]*ast.ArgumentDefinition, map[string]string, []string, error) {
 	argDefMap := make(map[string]*ast.ArgumentDefinition)
 	argValMap := make(map[string]string)
+	var dependentFields []string
 
 	if parentType.Name == "Query" || parentType.Name == "Mutation" {
 		parentFieldArgMap := getFieldArgDefsAsMap(parentField)
@@ -383,9 +432,42 @@ func getGivenQueryArgsAsMap(givenQuery *ast.Field, parentField *ast.FieldDefinit
 			argValMap[arg.Name] = varName
 		}
 	} else {
-		// TODO: handle @custom graphql validation for fields here
+		// @custom applied to a field of a non-root type: arguments can
+		// reference sibling fields of parentType using ${parent.field}
+		// instead of a GraphQL variable.
+		for _, arg := range givenQuery.Arguments {
+			varName := arg.Value.String()
+			siblingFieldName, ok := parentFieldReference(varName)
+			if !ok {
+				argValMap[arg.Name] = varName
+				continue
+			}
+			siblingField := parentType.Fields.ForName(siblingFieldName)
+			if siblingField == nil {
+				return nil, nil, nil, errors.Errorf(
+					"given field: %s: arg %s references %s, which is not a field of %s",
+					parentField.Name, arg.Name, varName, parentType.Name)
+			}
+			argDefMap[arg.Name] = &ast.ArgumentDefinition{
+				Name: arg.Name,
+				Type: siblingField.Type,
+			}
+			argValMap[arg.Name] = varName
+			dependentFields = append(dependentFields, siblingFieldName)
+		}
 	}
-	return argDefMap, argValMap
+	return argDefMap, argValMap, dependentFields, nil
+}
+
+// parentFieldReference reports whether varName is a ${parent.field} style
+// reference to a sibling field of the type @custom is applied on, returning
+// the referenced field name.
+func parentFieldReference(varName string) (string, bool) {
+	const prefix = "$parent."
+	if len(varName) <= len(prefix) || varName[:len(prefix)] != prefix {
+		return "", false
+	}
+	return varName[len(prefix):], true
 }
 
 func getFieldArgDefsAsMap(fieldDef *ast.FieldDefinition) map[string]*ast.ArgumentDefinition {
@@ -414,6 +496,82 @@ func getRemoteQueryArgsAsMap(remoteQuery *GqlField) (map[string]Args, []string)
 
 type IntrospectedSchema struct {
 	Data Data `json:"data"`
+
+	// index, once built by Index(), is the source of truth for type/field
+	// lookups; Data.Schema.Types is kept around for JSON round-tripping.
+	// indexOnce guards index's construction: the same *IntrospectedSchema is
+	// shared across concurrent callers via the introspection cache, so a bare
+	// nil-check would race.
+	index     *typeIndex
+	indexOnce sync.Once
+}
+
+// typeIndex holds O(1) type/field lookups for a remote schema, derived from
+// IntrospectedSchema.Data.Schema.Types.
+type typeIndex struct {
+	types       map[string]*Types
+	fields      map[string]map[string]*GqlField
+	inputFields map[string]map[string]*GqlField
+}
+
+// Index builds s's lookup maps from Data.Schema.Types. It's safe to call
+// concurrently and from multiple goroutines sharing the same
+// *IntrospectedSchema (e.g. via the introspection cache): the maps are built
+// at most once, guarded by indexOnce.
+func (s *IntrospectedSchema) Index() {
+	s.indexOnce.Do(s.buildIndex)
+}
+
+// buildIndex does the actual work of constructing s.index; only call it
+// through Index, which guarantees it runs at most once per schema.
+func (s *IntrospectedSchema) buildIndex() {
+	types := s.Data.Schema.Types
+	idx := &typeIndex{
+		types:       make(map[string]*Types, len(types)),
+		fields:      make(map[string]map[string]*GqlField, len(types)),
+		inputFields: make(map[string]map[string]*GqlField, len(types)),
+	}
+	for i := range types {
+		typ := &types[i]
+		idx.types[typ.Name] = typ
+
+		fields := make(map[string]*GqlField, len(typ.Fields))
+		for j := range typ.Fields {
+			fields[typ.Fields[j].Name] = &typ.Fields[j]
+		}
+		idx.fields[typ.Name] = fields
+
+		inputFields := make(map[string]*GqlField, len(typ.InputFields))
+		for j := range typ.InputFields {
+			inputFields[typ.InputFields[j].Name] = &typ.InputFields[j]
+		}
+		idx.inputFields[typ.Name] = inputFields
+	}
+	s.index = idx
+}
+
+// typeByName returns the Types entry named name, building the index first if
+// it hasn't been built yet.
+func (s *IntrospectedSchema) typeByName(name string) (*Types, bool) {
+	s.Index()
+	typ, ok := s.index.types[name]
+	return typ, ok
+}
+
+// fieldByName returns the GqlField named fieldName on typeName, building the
+// index first if it hasn't been built yet.
+func (s *IntrospectedSchema) fieldByName(typeName, fieldName string) (*GqlField, bool) {
+	s.Index()
+	field, ok := s.index.fields[typeName][fieldName]
+	return field, ok
+}
+
+// inputFieldByName returns the GqlField named fieldName amongst typeName's
+// input fields, building the index first if it hasn't been built yet.
+func (s *IntrospectedSchema) inputFieldByName(typeName, fieldName string) (*GqlField, bool) {
+	s.Index()
+	field, ok := s.index.inputFields[typeName][fieldName]
+	return field, ok
 }
 type IntrospectionQueryType struct {
 	Name string `json:"name"`
@@ -436,8 +594,16 @@ type Types struct {
 	Fields        []GqlField    `json:"fields"`
 	InputFields   []GqlField    `json:"inputFields"`
 	Interfaces    []interface{} `json:"interfaces"`
-	EnumValues    interface{}   `json:"enumValues"`
-	PossibleTypes interface{}   `json:"possibleTypes"`
+	EnumValues    []EnumValue   `json:"enumValues"`
+	// PossibleTypes lists the concrete types a UNION or INTERFACE can resolve
+	// to; it's used to validate inline fragments and fragment spreads against
+	// the remote schema.
+	PossibleTypes []*GqlType `json:"possibleTypes"`
+}
+type EnumValue struct {
+	Name              string      `json:"name"`
+	IsDeprecated      bool        `json:"isDeprecated"`
+	DeprecationReason interface{} `json:"deprecationReason"`
 }
 type Args struct {
 	Name         string      `json:"name"`
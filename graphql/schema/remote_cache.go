@@ -0,0 +1,202 @@
+/*
+ * Copyright 2020 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package schema
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"golang.org/x/sync/singleflight"
+)
+
+// defaultIntrospectionTTL is how long a cached introspection result is served
+// before it's considered stale and revalidated against the remote.
+const defaultIntrospectionTTL = 10 * time.Minute
+
+// defaultIntrospectionCacheSize bounds the number of distinct remote URLs the
+// cache will hold before evicting the least recently used entry.
+const defaultIntrospectionCacheSize = 256
+
+// introspectionCacheEntry is what's stored in the introspectionCache for a
+// single remote URL.
+type introspectionCacheEntry struct {
+	schema      *IntrospectedSchema
+	fingerprint string
+	etag        string
+	fetchedAt   time.Time
+	lastUsed    time.Time
+}
+
+// introspectionCache caches parsed remote introspection results, keyed by URL,
+// so that repeated @custom fields pointing at the same remote don't each pay
+// the cost of a fresh introspection query. It is safe for concurrent use and
+// is shared across all @custom fields pointing at the same URL.
+type introspectionCache struct {
+	ttl     time.Duration
+	maxSize int
+
+	mu      sync.Mutex
+	entries map[string]*introspectionCacheEntry
+
+	group singleflight.Group
+}
+
+// defaultIntrospectionCache is the process-wide cache used by
+// introspectRemoteSchema. Tests may construct their own introspectionCache to
+// avoid sharing state.
+var defaultIntrospectionCache = newIntrospectionCache(defaultIntrospectionTTL,
+	defaultIntrospectionCacheSize)
+
+func newIntrospectionCache(ttl time.Duration, maxSize int) *introspectionCache {
+	return &introspectionCache{
+		ttl:     ttl,
+		maxSize: maxSize,
+		entries: make(map[string]*introspectionCacheEntry),
+	}
+}
+
+// get returns the cached entry for url, and whether it is still within its
+// TTL. An entry past its TTL is still returned (so its ETag can be used for
+// conditional revalidation) but fresh is false.
+func (c *introspectionCache) get(url string) (entry *introspectionCacheEntry, fresh bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[url]
+	if !ok {
+		return nil, false
+	}
+	e.lastUsed = time.Now()
+	return e, time.Since(e.fetchedAt) < c.ttl
+}
+
+// put stores schema under url, evicting the least recently used entry first
+// if the cache is at capacity. It returns true if the schema's fingerprint
+// differs from what was previously cached for url, so callers can log/emit a
+// metric on remote schema drift.
+func (c *introspectionCache) put(url string, schema *IntrospectedSchema, etag string) (changed bool) {
+	fingerprint := fingerprintSchema(schema)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	prev, existed := c.entries[url]
+	changed = existed && prev.fingerprint != fingerprint
+
+	if !existed && len(c.entries) >= c.maxSize {
+		c.evictLocked()
+	}
+	c.entries[url] = &introspectionCacheEntry{
+		schema:      schema,
+		fingerprint: fingerprint,
+		etag:        etag,
+		fetchedAt:   time.Now(),
+		lastUsed:    time.Now(),
+	}
+	return changed
+}
+
+// evictLocked removes the least recently used entry. c.mu must be held.
+func (c *introspectionCache) evictLocked() {
+	var oldestURL string
+	var oldest time.Time
+	for url, e := range c.entries {
+		if oldestURL == "" || e.lastUsed.Before(oldest) {
+			oldestURL = url
+			oldest = e.lastUsed
+		}
+	}
+	if oldestURL != "" {
+		delete(c.entries, oldestURL)
+	}
+}
+
+// invalidate removes the cached entry for url, if any, forcing the next
+// introspection of url to fetch fresh from the remote.
+func (c *introspectionCache) invalidate(url string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, url)
+}
+
+// InvalidateRemoteSchema forces the next introspection of url to bypass the
+// shared introspection cache and fetch fresh from the remote, hot-reloading
+// that single remote schema.
+func InvalidateRemoteSchema(url string) {
+	defaultIntrospectionCache.invalidate(url)
+}
+
+// fingerprintSchema returns a sha256 fingerprint of schema re-encoded as JSON,
+// used to detect when a remote schema has changed between fetches. It hashes
+// the parsed IntrospectedSchema, not the raw response body, so parts of the
+// response that IntrospectedSchema doesn't capture don't affect it.
+func fingerprintSchema(schema *IntrospectedSchema) string {
+	body, err := json.Marshal(schema)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])
+}
+
+// resolveWithCache returns the introspected schema for url, fetching it with
+// fetch if there's no fresh cache entry. Concurrent calls for the same url are
+// coalesced via singleflight so that only one fetch is in flight at a time.
+func (c *introspectionCache) resolveWithCache(
+	url string, fetch func(etag string) (*IntrospectedSchema, string, error)) (*IntrospectedSchema, error) {
+
+	entry, fresh := c.get(url)
+	if fresh {
+		return entry.schema, nil
+	}
+
+	etag := ""
+	if entry != nil {
+		etag = entry.etag
+	}
+
+	v, err, _ := c.group.Do(url, func() (interface{}, error) {
+		// Re-check: another goroutine may have refreshed this URL while we
+		// were waiting to enter the singleflight group.
+		if e, fresh := c.get(url); fresh {
+			return e.schema, nil
+		}
+
+		schema, respEtag, err := fetch(etag)
+		if err != nil {
+			return nil, err
+		}
+		if schema == nil {
+			// 304 Not Modified: the cached entry is still valid, just refresh
+			// its fetchedAt/etag bookkeeping.
+			schema = entry.schema
+			respEtag = entry.etag
+		}
+		if changed := c.put(url, schema, respEtag); changed {
+			glog.Infof("remote schema fingerprint changed for %s", url)
+		}
+		return schema, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.(*IntrospectedSchema), nil
+}
@@ -0,0 +1,153 @@
+/*
+ * Copyright 2020 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package schema
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIntrospectionCache_ResolveWithCache_CachesFreshEntry(t *testing.T) {
+	cache := newIntrospectionCache(time.Minute, 10)
+	var calls int32
+	fetch := func(etag string) (*IntrospectedSchema, string, error) {
+		atomic.AddInt32(&calls, 1)
+		return &IntrospectedSchema{}, "etag-1", nil
+	}
+
+	_, err := cache.resolveWithCache("http://remote.example.com", fetch)
+	require.NoError(t, err)
+	_, err = cache.resolveWithCache("http://remote.example.com", fetch)
+	require.NoError(t, err)
+
+	require.EqualValues(t, 1, atomic.LoadInt32(&calls))
+}
+
+func TestIntrospectionCache_ResolveWithCache_RefetchesAfterTTL(t *testing.T) {
+	cache := newIntrospectionCache(time.Millisecond, 10)
+	var calls int32
+	fetch := func(etag string) (*IntrospectedSchema, string, error) {
+		atomic.AddInt32(&calls, 1)
+		return &IntrospectedSchema{}, "etag-1", nil
+	}
+
+	_, err := cache.resolveWithCache("http://remote.example.com", fetch)
+	require.NoError(t, err)
+
+	time.Sleep(5 * time.Millisecond)
+	_, err = cache.resolveWithCache("http://remote.example.com", fetch)
+	require.NoError(t, err)
+
+	require.EqualValues(t, 2, atomic.LoadInt32(&calls))
+}
+
+func TestIntrospectionCache_ResolveWithCache_ConditionalRevalidation(t *testing.T) {
+	cache := newIntrospectionCache(time.Millisecond, 10)
+	first := &IntrospectedSchema{}
+	var gotEtag string
+
+	_, err := cache.resolveWithCache("http://remote.example.com",
+		func(etag string) (*IntrospectedSchema, string, error) {
+			return first, "etag-1", nil
+		})
+	require.NoError(t, err)
+
+	time.Sleep(5 * time.Millisecond)
+	got, err := cache.resolveWithCache("http://remote.example.com",
+		func(etag string) (*IntrospectedSchema, string, error) {
+			gotEtag = etag
+			// 304 Not Modified: the fetch reports no new schema.
+			return nil, "", nil
+		})
+	require.NoError(t, err)
+	require.Equal(t, "etag-1", gotEtag)
+	require.Same(t, first, got)
+}
+
+func TestIntrospectionCache_ResolveWithCache_CoalescesConcurrentCalls(t *testing.T) {
+	cache := newIntrospectionCache(time.Minute, 10)
+	var calls int32
+	release := make(chan struct{})
+	fetch := func(etag string) (*IntrospectedSchema, string, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return &IntrospectedSchema{}, "etag-1", nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := cache.resolveWithCache("http://remote.example.com", fetch)
+			require.NoError(t, err)
+		}()
+	}
+	close(release)
+	wg.Wait()
+
+	require.EqualValues(t, 1, atomic.LoadInt32(&calls))
+}
+
+func TestIntrospectionCache_Invalidate(t *testing.T) {
+	cache := newIntrospectionCache(time.Minute, 10)
+	var calls int32
+	fetch := func(etag string) (*IntrospectedSchema, string, error) {
+		atomic.AddInt32(&calls, 1)
+		return &IntrospectedSchema{}, "etag-1", nil
+	}
+
+	_, err := cache.resolveWithCache("http://remote.example.com", fetch)
+	require.NoError(t, err)
+	cache.invalidate("http://remote.example.com")
+	_, err = cache.resolveWithCache("http://remote.example.com", fetch)
+	require.NoError(t, err)
+
+	require.EqualValues(t, 2, atomic.LoadInt32(&calls))
+}
+
+func TestIntrospectionCache_EvictsLeastRecentlyUsedAtCapacity(t *testing.T) {
+	cache := newIntrospectionCache(time.Minute, 2)
+
+	cache.put("http://a.example.com", &IntrospectedSchema{}, "")
+	cache.put("http://b.example.com", &IntrospectedSchema{}, "")
+	// Touch "a" so it's more recently used than "b".
+	_, _ = cache.get("http://a.example.com")
+	cache.put("http://c.example.com", &IntrospectedSchema{}, "")
+
+	_, aOk := cache.get("http://a.example.com")
+	_, bOk := cache.get("http://b.example.com")
+	_, cOk := cache.get("http://c.example.com")
+
+	require.True(t, aOk)
+	require.False(t, bOk)
+	require.True(t, cOk)
+}
+
+func TestFingerprintSchema_ChangesWithContent(t *testing.T) {
+	a := &IntrospectedSchema{}
+	a.Data.Schema.QueryType.Name = "Query"
+	b := &IntrospectedSchema{}
+	b.Data.Schema.QueryType.Name = "OtherQuery"
+
+	require.NotEqual(t, fingerprintSchema(a), fingerprintSchema(b))
+	require.Equal(t, fingerprintSchema(a), fingerprintSchema(a))
+}
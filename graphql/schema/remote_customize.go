@@ -0,0 +1,436 @@
+/*
+ * Copyright 2020 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package schema
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/vektah/gqlparser/v2/ast"
+)
+
+// NameMapping describes how a set of remote names are translated into the local
+// schema's naming space. Mapping takes precedence over Prefix/Suffix: a name
+// with an explicit entry in Mapping (localName -> remoteName) is translated
+// using that entry, everything else is translated by adding/stripping Prefix
+// and Suffix.
+type NameMapping struct {
+	Prefix  string            `json:"prefix"`
+	Suffix  string            `json:"suffix"`
+	Mapping map[string]string `json:"mapping"`
+}
+
+// FieldNameMapping scopes a NameMapping to the fields of a single remote
+// parent type.
+type FieldNameMapping struct {
+	ParentType string `json:"parent_type"`
+	NameMapping
+}
+
+// RemoteGraphqlCustomization is the customization block that can be declared
+// alongside the url in @custom->http->graphql. It lets a user expose a remote
+// GraphQL endpoint under different root field, type and field names than the
+// ones the remote schema actually uses.
+type RemoteGraphqlCustomization struct {
+	// RootFieldsNamespace, if non-empty, is the name of a wrapper field under
+	// which all of the remote endpoint's root fields are exposed locally.
+	RootFieldsNamespace string             `json:"root_fields_namespace"`
+	TypeNames           NameMapping        `json:"type_names"`
+	FieldNames          []FieldNameMapping `json:"field_names"`
+}
+
+// remoteName translates localName into the remote's naming space.
+func (m NameMapping) remoteName(localName string) string {
+	if remote, ok := m.Mapping[localName]; ok {
+		return remote
+	}
+	return m.Prefix + localName + m.Suffix
+}
+
+// localName translates remoteName back into the local naming space. ok is
+// false if remoteName can't be produced by this mapping at all, i.e. it isn't
+// an explicit mapping target and doesn't carry the expected prefix/suffix.
+func (m NameMapping) localName(remoteName string) (string, bool) {
+	for local, remote := range m.Mapping {
+		if remote == remoteName {
+			return local, true
+		}
+	}
+	if len(remoteName) < len(m.Prefix)+len(m.Suffix) ||
+		remoteName[:len(m.Prefix)] != m.Prefix ||
+		remoteName[len(remoteName)-len(m.Suffix):] != m.Suffix {
+		return "", false
+	}
+	return remoteName[len(m.Prefix) : len(remoteName)-len(m.Suffix)], true
+}
+
+// fieldNames returns the NameMapping scoped to parentType, or the zero value
+// if no field_names entry was declared for it.
+func (c *RemoteGraphqlCustomization) fieldNames(parentType string) NameMapping {
+	for _, fn := range c.FieldNames {
+		if fn.ParentType == parentType {
+			return fn.NameMapping
+		}
+	}
+	return NameMapping{}
+}
+
+// RemoteTypeName translates a local type name into the name the remote schema
+// knows it by.
+func (c *RemoteGraphqlCustomization) RemoteTypeName(localName string) string {
+	if c == nil {
+		return localName
+	}
+	return c.TypeNames.remoteName(localName)
+}
+
+// LocalTypeName translates a remote type name into the local schema's naming
+// space.
+func (c *RemoteGraphqlCustomization) LocalTypeName(remoteName string) (string, bool) {
+	if c == nil {
+		return remoteName, true
+	}
+	return c.TypeNames.localName(remoteName)
+}
+
+// RemoteFieldName translates a local field of parentType into the name the
+// remote schema knows it by.
+func (c *RemoteGraphqlCustomization) RemoteFieldName(parentType, localName string) string {
+	if c == nil {
+		return localName
+	}
+	return c.fieldNames(parentType).remoteName(localName)
+}
+
+// LocalFieldName translates a remote field of parentType back into the local
+// schema's naming space.
+func (c *RemoteGraphqlCustomization) LocalFieldName(parentType, remoteName string) (string, bool) {
+	if c == nil {
+		return remoteName, true
+	}
+	return c.fieldNames(parentType).localName(remoteName)
+}
+
+// Validate checks that the customization describes a bijective renaming, i.e.
+// no two local names are mapped onto the same remote name, and that the
+// renamed types don't collide with types already declared in localSchema
+// unless that type is itself the intended rename target. This is checked for
+// every type declared in localSchema, not just the ones with an explicit
+// type_names.mapping entry, so a prefix/suffix-only customization is covered
+// too.
+func (c *RemoteGraphqlCustomization) Validate(localSchema *ast.Schema) error {
+	if c == nil {
+		return nil
+	}
+	if err := c.TypeNames.validateAgainstSchema("type_names", localSchema); err != nil {
+		return err
+	}
+	for _, fn := range c.FieldNames {
+		if err := validateBijective("field_names for "+fn.ParentType, fn.NameMapping); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateAgainstSchema checks that m, applied to every type declared in
+// localSchema, produces a bijective renaming - no two local type names
+// collapse onto the same remote name - and that none of the produced remote
+// names collide with an unrelated type already declared in localSchema.
+func (m NameMapping) validateAgainstSchema(context string, localSchema *ast.Schema) error {
+	seen := make(map[string]string, len(localSchema.Types))
+	for localName := range localSchema.Types {
+		if strings.HasPrefix(localName, "__") {
+			continue
+		}
+		remoteName := m.remoteName(localName)
+
+		if existing, ok := localSchema.Types[remoteName]; ok && existing.Name != localName {
+			return errors.Errorf(
+				"customization: %s mapping for %s collides with existing local type %s",
+				context, localName, remoteName)
+		}
+
+		if other, ok := seen[remoteName]; ok {
+			return errors.Errorf(
+				"customization: %s mapping is not bijective; both %s and %s map to %s",
+				context, other, localName, remoteName)
+		}
+		seen[remoteName] = localName
+	}
+	return nil
+}
+
+// validateBijective returns an error if mapping assigns the same remote name
+// to more than one local name.
+func validateBijective(context string, mapping NameMapping) error {
+	seen := make(map[string]string, len(mapping.Mapping))
+	for localName, remoteName := range mapping.Mapping {
+		if other, ok := seen[remoteName]; ok {
+			return errors.Errorf(
+				"customization: %s mapping is not bijective; both %s and %s map to %s",
+				context, other, localName, remoteName)
+		}
+		seen[remoteName] = localName
+	}
+	return nil
+}
+
+// localizeIntrospection returns a copy of remoteIntrospection with every type
+// and field name rewritten from the remote's naming space into the local
+// naming space, so that it can be compared directly against the local schema.
+// root_fields_namespace doesn't affect this rewrite: it only changes how root
+// fields are nested in outgoing/incoming traffic, handled by
+// RewriteOutgoingOperation and RewriteIncomingResponse below.
+func (c *RemoteGraphqlCustomization) localizeIntrospection(
+	remoteIntrospection *IntrospectedSchema) *IntrospectedSchema {
+	if c == nil {
+		return remoteIntrospection
+	}
+
+	localized := &IntrospectedSchema{Data: remoteIntrospection.Data}
+	localized.Data.Schema.Types = make([]Types, len(remoteIntrospection.Data.Schema.Types))
+	for i, typ := range remoteIntrospection.Data.Schema.Types {
+		localized.Data.Schema.Types[i] = c.localizeType(typ)
+	}
+	// The root operation type names themselves fall under type_names, e.g. a
+	// blanket prefix/suffix customization renames Query/Mutation too, so they
+	// must be localized just like every other type name.
+	localized.Data.Schema.QueryType.Name = c.localTypeNameOrSame(remoteIntrospection.Data.Schema.QueryType.Name)
+	localized.Data.Schema.MutationType.Name =
+		c.localTypeNameOrSame(remoteIntrospection.Data.Schema.MutationType.Name)
+	localized.Data.Schema.SubscriptionType.Name =
+		c.localTypeNameOrSame(remoteIntrospection.Data.Schema.SubscriptionType.Name)
+	return localized
+}
+
+// localTypeNameOrSame translates remoteName into the local naming space,
+// falling back to remoteName unchanged if it isn't covered by the
+// customization (or remoteName is empty, as it is when the remote has no
+// subscription type).
+func (c *RemoteGraphqlCustomization) localTypeNameOrSame(remoteName string) string {
+	if remoteName == "" {
+		return remoteName
+	}
+	if localName, ok := c.LocalTypeName(remoteName); ok {
+		return localName
+	}
+	return remoteName
+}
+
+func (c *RemoteGraphqlCustomization) localizeType(typ Types) Types {
+	if localName, ok := c.LocalTypeName(typ.Name); ok {
+		typ.Name = localName
+	}
+	typ.Fields = c.localizeFields(typ.Name, typ.Fields)
+	typ.InputFields = c.localizeFields(typ.Name, typ.InputFields)
+	return typ
+}
+
+func (c *RemoteGraphqlCustomization) localizeFields(parentType string, fields []GqlField) []GqlField {
+	localized := make([]GqlField, len(fields))
+	for i, field := range fields {
+		if localName, ok := c.LocalFieldName(parentType, field.Name); ok {
+			field.Name = localName
+		}
+		field.Type = c.localizeGqlType(field.Type)
+		localized[i] = field
+	}
+	return localized
+}
+
+func (c *RemoteGraphqlCustomization) localizeGqlType(typ *GqlType) *GqlType {
+	if typ == nil {
+		return nil
+	}
+	localized := *typ
+	if localName, ok := c.LocalTypeName(typ.Name); ok {
+		localized.Name = localName
+	}
+	localized.OfType = c.localizeGqlType(typ.OfType)
+	return &localized
+}
+
+// RewriteOutgoingOperation rewrites opDef in place, translating it from the
+// local naming space back into the remote's, so it can be sent over the
+// wire as-is. rootTypeName is the local type opDef's selection set starts
+// from (e.g. "Query"/"Mutation"). If RootFieldsNamespace is set and opDef's
+// selection set is just that single wrapper field, it's unwrapped so the
+// remote sees its root fields directly.
+func (c *RemoteGraphqlCustomization) RewriteOutgoingOperation(localSchema *ast.Schema,
+	opDef *ast.OperationDefinition, rootTypeName string) {
+	if c == nil {
+		return
+	}
+	for _, v := range opDef.VariableDefinitions {
+		c.rewriteTypeToRemote(v.Type)
+	}
+	var wrapperType string
+	opDef.SelectionSet, wrapperType = c.unwrapRootNamespace(localSchema, rootTypeName, opDef.SelectionSet)
+	c.rewriteSelectionSetToRemote(localSchema, wrapperType, opDef.SelectionSet)
+}
+
+// unwrapRootNamespace drops the RootFieldsNamespace wrapper field, if set is
+// exactly that one field, returning its selection set and the local type the
+// wrapper field is declared as (i.e. the parent type its own fields are
+// declared on) instead of rootTypeName.
+func (c *RemoteGraphqlCustomization) unwrapRootNamespace(localSchema *ast.Schema, rootTypeName string,
+	set ast.SelectionSet) (ast.SelectionSet, string) {
+	if c.RootFieldsNamespace == "" || len(set) != 1 {
+		return set, rootTypeName
+	}
+	namespaceField, ok := set[0].(*ast.Field)
+	if !ok || namespaceField.Name != c.RootFieldsNamespace {
+		return set, rootTypeName
+	}
+	return namespaceField.SelectionSet, c.namespaceWrapperType(localSchema, rootTypeName)
+}
+
+// namespaceWrapperType returns the local type that RootFieldsNamespace's own
+// fields are declared on, i.e. the declared type of the wrapper field itself
+// on rootTypeName. Falls back to rootTypeName if it can't be resolved.
+func (c *RemoteGraphqlCustomization) namespaceWrapperType(localSchema *ast.Schema, rootTypeName string) string {
+	if localSchema == nil {
+		return rootTypeName
+	}
+	rootDef := localSchema.Types[rootTypeName]
+	if rootDef == nil {
+		return rootTypeName
+	}
+	fieldDef := rootDef.Fields.ForName(c.RootFieldsNamespace)
+	if fieldDef == nil {
+		return rootTypeName
+	}
+	return fieldDef.Type.Name()
+}
+
+// rewriteSelectionSetToRemote recursively rewrites set's field and type
+// condition names from the local naming space into the remote's, using
+// localSchema to resolve each field's declared type so the recursion stays in
+// the local naming space that FieldNames.ParentType is declared against.
+func (c *RemoteGraphqlCustomization) rewriteSelectionSetToRemote(localSchema *ast.Schema,
+	localParentType string, set ast.SelectionSet) {
+	var parentDef *ast.Definition
+	if localSchema != nil {
+		parentDef = localSchema.Types[localParentType]
+	}
+	for _, sel := range set {
+		switch f := sel.(type) {
+		case *ast.Field:
+			localFieldName := f.Name
+			var localChildType string
+			if parentDef != nil {
+				if fieldDef := parentDef.Fields.ForName(localFieldName); fieldDef != nil {
+					localChildType = fieldDef.Type.Name()
+				}
+			}
+			f.Name = c.RemoteFieldName(localParentType, localFieldName)
+			if len(f.SelectionSet) > 0 {
+				c.rewriteSelectionSetToRemote(localSchema, localChildType, f.SelectionSet)
+			}
+		case *ast.InlineFragment:
+			localTypeCondition := f.TypeCondition
+			f.TypeCondition = c.RemoteTypeName(localTypeCondition)
+			c.rewriteSelectionSetToRemote(localSchema, localTypeCondition, f.SelectionSet)
+		case *ast.FragmentSpread:
+			localTypeCondition := f.Definition.TypeCondition
+			f.Definition.TypeCondition = c.RemoteTypeName(localTypeCondition)
+			c.rewriteSelectionSetToRemote(localSchema, localTypeCondition, f.Definition.SelectionSet)
+		}
+	}
+}
+
+// rewriteTypeToRemote rewrites t's named type from the local naming space
+// into the remote's, recursing through LIST/NON_NULL wrappers.
+func (c *RemoteGraphqlCustomization) rewriteTypeToRemote(t *ast.Type) {
+	if t == nil {
+		return
+	}
+	if t.NamedType != "" {
+		t.NamedType = c.RemoteTypeName(t.NamedType)
+	}
+	c.rewriteTypeToRemote(t.Elem)
+}
+
+// RewriteIncomingResponse translates resp's keys from the remote's naming
+// space back into the local one, recursing into nested objects/arrays using
+// localSchema to find each field's declared type. If RootFieldsNamespace is
+// set, the result is re-wrapped under that field so it matches what the
+// local client selected.
+func (c *RemoteGraphqlCustomization) RewriteIncomingResponse(localSchema *ast.Schema,
+	localParentType string, resp map[string]interface{}) map[string]interface{} {
+	if c == nil {
+		return resp
+	}
+	wrapperType := localParentType
+	if c.RootFieldsNamespace != "" {
+		wrapperType = c.namespaceWrapperType(localSchema, localParentType)
+	}
+	localized := c.localizeFieldKeys(localSchema, wrapperType, resp)
+	if c.RootFieldsNamespace != "" {
+		return map[string]interface{}{c.RootFieldsNamespace: localized}
+	}
+	return localized
+}
+
+// localizeFieldKeys translates obj's keys from the remote's naming space back
+// into the local one, scoped to localParentType's field_names customization,
+// and recurses into nested values via localizeValue.
+func (c *RemoteGraphqlCustomization) localizeFieldKeys(localSchema *ast.Schema,
+	localParentType string, obj map[string]interface{}) map[string]interface{} {
+	var parentDef *ast.Definition
+	if localSchema != nil {
+		parentDef = localSchema.Types[localParentType]
+	}
+
+	localized := make(map[string]interface{}, len(obj))
+	for remoteKey, value := range obj {
+		localKey, ok := c.LocalFieldName(localParentType, remoteKey)
+		if !ok {
+			localKey = remoteKey
+		}
+		var localChildType string
+		if parentDef != nil {
+			if fieldDef := parentDef.Fields.ForName(localKey); fieldDef != nil {
+				localChildType = fieldDef.Type.Name()
+			}
+		}
+		localized[localKey] = c.localizeValue(localSchema, localChildType, value)
+	}
+	return localized
+}
+
+// localizeValue recurses localizeFieldKeys into nested objects and arrays;
+// scalar values are returned unchanged.
+func (c *RemoteGraphqlCustomization) localizeValue(localSchema *ast.Schema, localType string,
+	value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if localType == "" {
+			return v
+		}
+		return c.localizeFieldKeys(localSchema, localType, v)
+	case []interface{}:
+		localized := make([]interface{}, len(v))
+		for i, elem := range v {
+			localized[i] = c.localizeValue(localSchema, localType, elem)
+		}
+		return localized
+	default:
+		return v
+	}
+}
@@ -0,0 +1,220 @@
+/*
+ * Copyright 2020 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package schema
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/vektah/gqlparser/v2/ast"
+)
+
+func TestNameMapping_RemoteLocalRoundTrip(t *testing.T) {
+	prefixed := NameMapping{Prefix: "Remote", Suffix: "Type"}
+	require.Equal(t, "RemoteAuthorType", prefixed.remoteName("Author"))
+	local, ok := prefixed.localName("RemoteAuthorType")
+	require.True(t, ok)
+	require.Equal(t, "Author", local)
+	_, ok = prefixed.localName("Unrelated")
+	require.False(t, ok)
+
+	mapped := NameMapping{Mapping: map[string]string{"Author": "Writer"}}
+	require.Equal(t, "Writer", mapped.remoteName("Author"))
+	local, ok = mapped.localName("Writer")
+	require.True(t, ok)
+	require.Equal(t, "Author", local)
+}
+
+func TestRemoteGraphqlCustomization_Validate(t *testing.T) {
+	localSchema := &ast.Schema{
+		Types: map[string]*ast.Definition{
+			"Author":       {Name: "Author"},
+			"Post":         {Name: "Post"},
+			"RemoteAuthor": {Name: "RemoteAuthor"},
+		},
+	}
+
+	tests := []struct {
+		name    string
+		custom  *RemoteGraphqlCustomization
+		wantErr string
+	}{
+		{
+			name:   "nil customization is valid",
+			custom: nil,
+		},
+		{
+			name: "prefix customization with no collisions is valid",
+			custom: &RemoteGraphqlCustomization{
+				TypeNames: NameMapping{Prefix: "Ext"},
+			},
+			wantErr: "",
+		},
+		{
+			name: "mapping customization colliding with an existing local type",
+			custom: &RemoteGraphqlCustomization{
+				TypeNames: NameMapping{Mapping: map[string]string{"Author": "RemoteAuthor"}},
+			},
+			wantErr: "collides with existing local type",
+		},
+		{
+			name: "non-bijective field_names mapping",
+			custom: &RemoteGraphqlCustomization{
+				FieldNames: []FieldNameMapping{
+					{
+						ParentType: "Author",
+						NameMapping: NameMapping{
+							Mapping: map[string]string{"name": "title", "fullName": "title"},
+						},
+					},
+				},
+			},
+			wantErr: "is not bijective",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.custom.Validate(localSchema)
+			if tc.wantErr == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			require.Contains(t, err.Error(), tc.wantErr)
+		})
+	}
+}
+
+func TestLocalizeIntrospection(t *testing.T) {
+	custom := &RemoteGraphqlCustomization{
+		TypeNames: NameMapping{Prefix: "Remote"},
+	}
+	remoteIntrospection := &IntrospectedSchema{}
+	remoteIntrospection.Data.Schema.QueryType.Name = "RemoteQuery"
+	remoteIntrospection.Data.Schema.MutationType.Name = "RemoteMutation"
+	remoteIntrospection.Data.Schema.Types = []Types{
+		{
+			Kind: "OBJECT",
+			Name: "RemoteAuthor",
+			Fields: []GqlField{
+				{Name: "name", Type: &GqlType{Kind: "SCALAR", Name: "String"}},
+			},
+		},
+	}
+
+	localized := custom.localizeIntrospection(remoteIntrospection)
+
+	require.Equal(t, "Query", localized.Data.Schema.QueryType.Name)
+	require.Equal(t, "Mutation", localized.Data.Schema.MutationType.Name)
+	require.Equal(t, "Author", localized.Data.Schema.Types[0].Name)
+	// The original must be untouched: localizeIntrospection must not mutate
+	// its input in place.
+	require.Equal(t, "RemoteQuery", remoteIntrospection.Data.Schema.QueryType.Name)
+	require.Equal(t, "RemoteAuthor", remoteIntrospection.Data.Schema.Types[0].Name)
+}
+
+// buildNamespacedSchema returns a local schema where Query has a single
+// "remote" namespace field of type RemoteQuery, which itself declares a
+// "getAuthor" field returning Author.
+func buildNamespacedSchema() *ast.Schema {
+	authorType := &ast.Definition{
+		Name: "Author",
+		Fields: ast.FieldList{
+			{Name: "name", Type: &ast.Type{NamedType: "String"}},
+		},
+	}
+	remoteQueryType := &ast.Definition{
+		Name: "RemoteQuery",
+		Fields: ast.FieldList{
+			{Name: "getAuthor", Type: &ast.Type{NamedType: "Author"}},
+		},
+	}
+	queryType := &ast.Definition{
+		Name: "Query",
+		Fields: ast.FieldList{
+			{Name: "remote", Type: &ast.Type{NamedType: "RemoteQuery"}},
+		},
+	}
+	return &ast.Schema{
+		Types: map[string]*ast.Definition{
+			"Author":      authorType,
+			"RemoteQuery": remoteQueryType,
+			"Query":       queryType,
+		},
+	}
+}
+
+func TestRewriteOutgoingOperation_NamespaceUsesWrapperFieldType(t *testing.T) {
+	custom := &RemoteGraphqlCustomization{
+		RootFieldsNamespace: "remote",
+		FieldNames: []FieldNameMapping{
+			{ParentType: "Author", NameMapping: NameMapping{Mapping: map[string]string{"name": "fullName"}}},
+		},
+	}
+	localSchema := buildNamespacedSchema()
+
+	getAuthor := &ast.Field{
+		Name: "getAuthor",
+		SelectionSet: ast.SelectionSet{
+			&ast.Field{Name: "name"},
+		},
+	}
+	namespaceField := &ast.Field{
+		Name:         "remote",
+		SelectionSet: ast.SelectionSet{getAuthor},
+	}
+	opDef := &ast.OperationDefinition{
+		Operation:    ast.Query,
+		SelectionSet: ast.SelectionSet{namespaceField},
+	}
+
+	custom.RewriteOutgoingOperation(localSchema, opDef, "Query")
+
+	// The namespace wrapper itself is unwrapped away.
+	require.Equal(t, ast.SelectionSet{getAuthor}, opDef.SelectionSet)
+	// Author.name must have been rewritten using Author as the parent type,
+	// not "Query" - this only happens if the wrapper field's own declared
+	// type (RemoteQuery) was used to recurse into getAuthor's return type.
+	require.Equal(t, "fullName", getAuthor.SelectionSet[0].(*ast.Field).Name)
+}
+
+func TestRewriteIncomingResponse_NamespaceUsesWrapperFieldType(t *testing.T) {
+	custom := &RemoteGraphqlCustomization{
+		RootFieldsNamespace: "remote",
+		FieldNames: []FieldNameMapping{
+			{ParentType: "Author", NameMapping: NameMapping{Mapping: map[string]string{"name": "fullName"}}},
+		},
+	}
+	localSchema := buildNamespacedSchema()
+
+	resp := map[string]interface{}{
+		"getAuthor": map[string]interface{}{
+			"fullName": "Ada Lovelace",
+		},
+	}
+
+	got := custom.RewriteIncomingResponse(localSchema, "Query", resp)
+
+	require.Equal(t, map[string]interface{}{
+		"remote": map[string]interface{}{
+			"getAuthor": map[string]interface{}{
+				"name": "Ada Lovelace",
+			},
+		},
+	}, got)
+}
@@ -0,0 +1,169 @@
+/*
+ * Copyright 2020 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package schema
+
+import (
+	"crypto/tls"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// defaultRemoteTimeout is used when a RemoteEndpointConfig doesn't specify
+// TimeoutSeconds.
+const defaultRemoteTimeout = 5 * time.Second
+
+// envInterpolation matches `{{env "NAME"}}` inside a static header value.
+var envInterpolation = regexp.MustCompile(`{{\s*env\s+"([^"]+)"\s*}}`)
+
+// RemoteEndpointConfig describes how to authenticate to, and forward client
+// context to, a single remote GraphQL endpoint. The same config is consumed
+// both when introspecting the endpoint's schema and when executing queries
+// against it at request time, so that introspection sees the same auth as
+// runtime calls.
+type RemoteEndpointConfig struct {
+	// ForwardHeaders lists header names (or regexes, when wrapped in `/.../`)
+	// to copy verbatim from the incoming client request onto the remote call.
+	ForwardHeaders []string `json:"forward_headers"`
+	// ForwardClientHeaders, if true, forwards all incoming client headers
+	// except for hop-by-hop ones.
+	ForwardClientHeaders bool `json:"forward_client_headers"`
+	// Headers are static headers added to every call to this endpoint. A
+	// value like `Bearer {{env "REMOTE_TOKEN"}}` is interpolated from the
+	// environment at call time.
+	Headers map[string]string `json:"headers"`
+	// TimeoutSeconds overrides defaultRemoteTimeout when non-zero.
+	TimeoutSeconds int `json:"timeout_seconds"`
+	// TLSCertFile and TLSKeyFile, when both set, configure mTLS client auth
+	// for calls to this endpoint.
+	TLSCertFile string `json:"tls_cert_file"`
+	TLSKeyFile  string `json:"tls_key_file"`
+}
+
+// timeout returns the configured timeout, or defaultRemoteTimeout if unset.
+func (c *RemoteEndpointConfig) timeout() time.Duration {
+	if c == nil || c.TimeoutSeconds <= 0 {
+		return defaultRemoteTimeout
+	}
+	return time.Duration(c.TimeoutSeconds) * time.Second
+}
+
+// client builds the *http.Client to use for calls to this endpoint,
+// configuring mTLS when a client cert/key pair is present.
+func (c *RemoteEndpointConfig) client() (*http.Client, error) {
+	client := &http.Client{Timeout: c.timeout()}
+	if c == nil || c.TLSCertFile == "" || c.TLSKeyFile == "" {
+		return client, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(c.TLSCertFile, c.TLSKeyFile)
+	if err != nil {
+		return nil, errors.Wrapf(err, "while loading client cert/key for remote endpoint")
+	}
+	client.Transport = &http.Transport{
+		TLSClientConfig: &tls.Config{Certificates: []tls.Certificate{cert}},
+	}
+	return client, nil
+}
+
+// applyStaticHeaders sets c's static headers (with env-var interpolation) on
+// req.
+func (c *RemoteEndpointConfig) applyStaticHeaders(req *http.Request) {
+	if c == nil {
+		return
+	}
+	for name, value := range c.Headers {
+		req.Header.Set(name, interpolateEnv(value))
+	}
+}
+
+// applyForwardedHeaders copies headers from incoming onto req, per c's
+// ForwardHeaders list and ForwardClientHeaders flag.
+func (c *RemoteEndpointConfig) applyForwardedHeaders(incoming http.Header, req *http.Request) {
+	if c == nil || incoming == nil {
+		return
+	}
+	if c.ForwardClientHeaders {
+		hopByHop := map[string]bool{
+			"Connection": true, "Proxy-Connection": true, "Keep-Alive": true,
+			"Proxy-Authenticate": true, "Proxy-Authorization": true, "Te": true,
+			"Trailer": true, "Transfer-Encoding": true, "Upgrade": true,
+		}
+		for name, values := range incoming {
+			if hopByHop[http.CanonicalHeaderKey(name)] {
+				continue
+			}
+			for _, v := range values {
+				req.Header.Add(name, v)
+			}
+		}
+		return
+	}
+	for _, pattern := range c.ForwardHeaders {
+		if strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/") && len(pattern) > 1 {
+			re, err := regexp.Compile(pattern[1 : len(pattern)-1])
+			if err != nil {
+				continue
+			}
+			for name, values := range incoming {
+				if !re.MatchString(name) {
+					continue
+				}
+				for _, v := range values {
+					req.Header.Add(name, v)
+				}
+			}
+			continue
+		}
+		if v := incoming.Get(pattern); v != "" {
+			req.Header.Set(pattern, v)
+		}
+	}
+}
+
+// interpolateEnv replaces every `{{env "NAME"}}` occurrence in value with the
+// current value of the NAME environment variable.
+func interpolateEnv(value string) string {
+	return envInterpolation.ReplaceAllStringFunc(value, func(match string) string {
+		name := envInterpolation.FindStringSubmatch(match)[1]
+		return os.Getenv(name)
+	})
+}
+
+// newRemoteRequest builds an *http.Request for url/body configured per
+// endpointConfig: static + forwarded headers, and Content-Type: application/json.
+func newRemoteRequest(url string, body []byte, incoming http.Header,
+	endpointConfig *RemoteEndpointConfig) (*http.Request, *http.Client, error) {
+
+	req, err := http.NewRequest(http.MethodPost, url, strings.NewReader(string(body)))
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	endpointConfig.applyForwardedHeaders(incoming, req)
+	endpointConfig.applyStaticHeaders(req)
+
+	client, err := endpointConfig.client()
+	if err != nil {
+		return nil, nil, err
+	}
+	return req, client, nil
+}
@@ -0,0 +1,123 @@
+/*
+ * Copyright 2020 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package schema
+
+import (
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRemoteEndpointConfig_ApplyForwardedHeaders(t *testing.T) {
+	incoming := http.Header{
+		"Authorization":   []string{"Bearer client-token"},
+		"X-Request-Id":    []string{"abc-123"},
+		"X-Internal-Only": []string{"should-not-forward"},
+	}
+
+	tests := []struct {
+		name   string
+		config *RemoteEndpointConfig
+		want   http.Header
+	}{
+		{
+			name:   "explicit header list",
+			config: &RemoteEndpointConfig{ForwardHeaders: []string{"Authorization"}},
+			want: http.Header{
+				"Authorization": []string{"Bearer client-token"},
+			},
+		},
+		{
+			name:   "regex header list",
+			config: &RemoteEndpointConfig{ForwardHeaders: []string{"/^X-/"}},
+			want: http.Header{
+				"X-Request-Id":    []string{"abc-123"},
+				"X-Internal-Only": []string{"should-not-forward"},
+			},
+		},
+		{
+			name:   "forward all client headers",
+			config: &RemoteEndpointConfig{ForwardClientHeaders: true},
+			want: http.Header{
+				"Authorization":   []string{"Bearer client-token"},
+				"X-Request-Id":    []string{"abc-123"},
+				"X-Internal-Only": []string{"should-not-forward"},
+			},
+		},
+		{
+			name:   "no forwarding configured",
+			config: &RemoteEndpointConfig{},
+			want:   http.Header{},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodPost, "http://remote.example.com", nil)
+			require.NoError(t, err)
+
+			tc.config.applyForwardedHeaders(incoming, req)
+			for name, values := range tc.want {
+				require.Equal(t, values, req.Header.Values(name))
+			}
+			require.Len(t, req.Header, len(tc.want))
+		})
+	}
+}
+
+func TestRemoteEndpointConfig_EnvInterpolation(t *testing.T) {
+	require.NoError(t, os.Setenv("REMOTE_TOKEN", "secret-token"))
+	defer os.Unsetenv("REMOTE_TOKEN")
+
+	config := &RemoteEndpointConfig{
+		Headers: map[string]string{
+			"Authorization": `Bearer {{env "REMOTE_TOKEN"}}`,
+		},
+	}
+
+	req, err := http.NewRequest(http.MethodPost, "http://remote.example.com", nil)
+	require.NoError(t, err)
+
+	config.applyStaticHeaders(req)
+	require.Equal(t, "Bearer secret-token", req.Header.Get("Authorization"))
+}
+
+func TestRemoteEndpointConfig_Timeout(t *testing.T) {
+	require.Equal(t, defaultRemoteTimeout, (&RemoteEndpointConfig{}).timeout())
+	require.Equal(t, 0, int((&RemoteEndpointConfig{TimeoutSeconds: 0}).timeout()-defaultRemoteTimeout))
+
+	config := &RemoteEndpointConfig{TimeoutSeconds: 30}
+	require.Equal(t, int64(30), int64(config.timeout().Seconds()))
+}
+
+func TestRemoteEndpointConfig_TLSWithoutCertIsPlainClient(t *testing.T) {
+	config := &RemoteEndpointConfig{}
+	client, err := config.client()
+	require.NoError(t, err)
+	require.Nil(t, client.Transport)
+}
+
+func TestRemoteEndpointConfig_TLSMissingFileErrors(t *testing.T) {
+	config := &RemoteEndpointConfig{
+		TLSCertFile: "testdata/does-not-exist.crt",
+		TLSKeyFile:  "testdata/does-not-exist.key",
+	}
+	_, err := config.client()
+	require.Error(t, err)
+}
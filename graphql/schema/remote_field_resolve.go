@@ -0,0 +1,195 @@
+/*
+ * Copyright 2020 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package schema
+
+import (
+	"github.com/pkg/errors"
+)
+
+// FieldResolutionMode determines how a @custom(http:{graphql:...}) field on a
+// non-root type is resolved against the remote endpoint.
+type FieldResolutionMode int
+
+const (
+	// PerItemResolution issues one remote call per parent object.
+	PerItemResolution FieldResolutionMode = iota
+	// BatchResolution gathers N parent values into a list variable and issues
+	// a single remote call that returns a list, which is then scattered back
+	// to the N parent objects.
+	BatchResolution
+)
+
+// fieldResolutionMetadata records what's needed to resolve a @custom field on
+// a non-root type: which sibling fields of the parent must be fetched, which
+// mode to resolve it in, and - for batch mode - which remote arg the batch
+// keys are passed through.
+type fieldResolutionMetadata struct {
+	dependentFields []string
+	mode            FieldResolutionMode
+	batchKeyArg     string
+}
+
+// validateFieldResolutionMode determines whether introspectedRemoteQuery
+// should be resolved per-item or in batch, and validates it accordingly.
+// A query whose return type isn't a list is resolved per-item. A query whose
+// return type is a list is resolved in batch mode, and fails validation unless
+// the list's element type matches the field's declared type and the query has
+// a NON_NULL list-of-scalar arg to carry the batch keys.
+func validateFieldResolutionMode(metadata *remoteGraphqlMetadata,
+	introspectedRemoteQuery *GqlField) error {
+	fr := metadata.fieldResolution
+	fieldTypeName := metadata.parentField.Type.Name()
+
+	if introspectedRemoteQuery.Type.Kind != "LIST" {
+		fr.mode = PerItemResolution
+		return nil
+	}
+
+	elemType := introspectedRemoteQuery.Type.OfType
+	if elemType == nil || elemType.NamedType() != fieldTypeName {
+		return errors.Errorf(
+			"given query: %s: batch return type must be a list of %s, got %s",
+			metadata.parentField.Name, fieldTypeName, introspectedRemoteQuery.Type.String())
+	}
+
+	batchKeyArg, ok := findBatchKeyArg(introspectedRemoteQuery)
+	if !ok {
+		return errors.Errorf(
+			"given query: %s: batch mode requires a NON_NULL list-of-scalar arg to carry the batch keys",
+			metadata.parentField.Name)
+	}
+
+	fr.mode = BatchResolution
+	fr.batchKeyArg = batchKeyArg
+	return nil
+}
+
+// findBatchKeyArg returns the name of the NON_NULL list-of-scalar arg (e.g.
+// `ids: [ID!]!`) that batch keys should be passed through.
+func findBatchKeyArg(remoteQuery *GqlField) (string, bool) {
+	for _, arg := range remoteQuery.Args {
+		argType := arg.Type
+		if argType.Kind != "NON_NULL" {
+			continue
+		}
+		argType = argType.OfType
+		if argType == nil || argType.Kind != "LIST" {
+			continue
+		}
+		if _, isScalar := graphqlScalarType[argType.OfType.NamedType()]; !isScalar {
+			continue
+		}
+		return arg.Name, true
+	}
+	return "", false
+}
+
+// buildBatchVariables gathers the batch key value out of each parent object
+// in parentValues (keyed by the dependent field that feeds the remote's batch
+// key arg) into the single list variable the batch query expects.
+func buildBatchVariables(batchKeyArg string, parentValues []map[string]interface{}) map[string]interface{} {
+	keys := make([]interface{}, len(parentValues))
+	for i, parentValue := range parentValues {
+		keys[i] = parentValue[batchKeyArg]
+	}
+	return map[string]interface{}{batchKeyArg: keys}
+}
+
+// scatterBatchResults maps a batch query's list response back onto each
+// parent object it was fetched for, by matching batchKeyArg's value in each
+// result item against the corresponding parent's value for that field.
+func scatterBatchResults(batchKeyArg string, parentValues []map[string]interface{},
+	results []map[string]interface{}) []interface{} {
+
+	byKey := make(map[interface{}]map[string]interface{}, len(results))
+	for _, result := range results {
+		byKey[result[batchKeyArg]] = result
+	}
+
+	scattered := make([]interface{}, len(parentValues))
+	for i, parentValue := range parentValues {
+		if result, ok := byKey[parentValue[batchKeyArg]]; ok {
+			scattered[i] = result
+		}
+	}
+	return scattered
+}
+
+// remoteFieldExecutor issues the actual remote call for a resolved field and
+// returns its raw result. What ResolveField passes as variables, and what it
+// expects back, depends on metadata.mode: a single list-shaped result for
+// BatchResolution, or one object per call for PerItemResolution. The round
+// trip itself is injected so this package doesn't need to own an HTTP client.
+type remoteFieldExecutor func(variables map[string]interface{}) (interface{}, error)
+
+// ResolveField resolves metadata's field for every object in parentValues,
+// calling exec either once in batch mode (gathering every parent's batch key
+// into a single list variable via buildBatchVariables and scattering the
+// list result back out via scatterBatchResults) or once per parent object in
+// per-item mode. The returned slice has one entry per parentValues entry, in
+// the same order.
+func ResolveField(metadata *fieldResolutionMetadata, parentValues []map[string]interface{},
+	exec remoteFieldExecutor) ([]interface{}, error) {
+	switch metadata.mode {
+	case BatchResolution:
+		return resolveBatchField(metadata, parentValues, exec)
+	default:
+		return resolvePerItemField(metadata, parentValues, exec)
+	}
+}
+
+// resolveBatchField issues exec once with every parent's batch key gathered
+// into a single list variable, then scatters the list response it gets back
+// onto the parent objects that requested it.
+func resolveBatchField(metadata *fieldResolutionMetadata, parentValues []map[string]interface{},
+	exec remoteFieldExecutor) ([]interface{}, error) {
+	variables := buildBatchVariables(metadata.batchKeyArg, parentValues)
+	raw, err := exec(variables)
+	if err != nil {
+		return nil, err
+	}
+	results, ok := raw.([]map[string]interface{})
+	if !ok {
+		return nil, errors.Errorf("batch resolution: expected a list of results from remote, got %T", raw)
+	}
+	return scatterBatchResults(metadata.batchKeyArg, parentValues, results), nil
+}
+
+// resolvePerItemField issues exec once per parent object, passing that
+// parent's dependent field values as variables.
+func resolvePerItemField(metadata *fieldResolutionMetadata, parentValues []map[string]interface{},
+	exec remoteFieldExecutor) ([]interface{}, error) {
+	resolved := make([]interface{}, len(parentValues))
+	for i, parentValue := range parentValues {
+		raw, err := exec(perItemVariables(metadata.dependentFields, parentValue))
+		if err != nil {
+			return nil, err
+		}
+		resolved[i] = raw
+	}
+	return resolved, nil
+}
+
+// perItemVariables extracts dependentFields' values out of parentValue to use
+// as a single per-item remote call's variables.
+func perItemVariables(dependentFields []string, parentValue map[string]interface{}) map[string]interface{} {
+	variables := make(map[string]interface{}, len(dependentFields))
+	for _, field := range dependentFields {
+		variables[field] = parentValue[field]
+	}
+	return variables
+}
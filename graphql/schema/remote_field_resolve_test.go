@@ -0,0 +1,102 @@
+/*
+ * Copyright 2020 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package schema
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveField_Batch(t *testing.T) {
+	metadata := &fieldResolutionMetadata{mode: BatchResolution, batchKeyArg: "id"}
+	parentValues := []map[string]interface{}{
+		{"id": "0x1"},
+		{"id": "0x2"},
+		{"id": "0x3"},
+	}
+
+	var gotVariables map[string]interface{}
+	exec := func(variables map[string]interface{}) (interface{}, error) {
+		gotVariables = variables
+		return []map[string]interface{}{
+			{"id": "0x2", "name": "b"},
+			{"id": "0x1", "name": "a"},
+			// 0x3 deliberately missing a result.
+		}, nil
+	}
+
+	resolved, err := ResolveField(metadata, parentValues, exec)
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{"id": []interface{}{"0x1", "0x2", "0x3"}}, gotVariables)
+	require.Equal(t, map[string]interface{}{"id": "0x1", "name": "a"}, resolved[0])
+	require.Equal(t, map[string]interface{}{"id": "0x2", "name": "b"}, resolved[1])
+	require.Nil(t, resolved[2])
+}
+
+func TestResolveField_Batch_WrongExecShapeErrors(t *testing.T) {
+	metadata := &fieldResolutionMetadata{mode: BatchResolution, batchKeyArg: "id"}
+	parentValues := []map[string]interface{}{{"id": "0x1"}}
+
+	exec := func(variables map[string]interface{}) (interface{}, error) {
+		return map[string]interface{}{"id": "0x1"}, nil
+	}
+
+	_, err := ResolveField(metadata, parentValues, exec)
+	require.Error(t, err)
+}
+
+func TestResolveField_PerItem(t *testing.T) {
+	metadata := &fieldResolutionMetadata{
+		mode:            PerItemResolution,
+		dependentFields: []string{"authorId"},
+	}
+	parentValues := []map[string]interface{}{
+		{"authorId": "0x1"},
+		{"authorId": "0x2"},
+	}
+
+	var gotVariables []map[string]interface{}
+	exec := func(variables map[string]interface{}) (interface{}, error) {
+		gotVariables = append(gotVariables, variables)
+		return map[string]interface{}{"name": variables["authorId"]}, nil
+	}
+
+	resolved, err := ResolveField(metadata, parentValues, exec)
+	require.NoError(t, err)
+	require.Equal(t, []map[string]interface{}{
+		{"authorId": "0x1"},
+		{"authorId": "0x2"},
+	}, gotVariables)
+	require.Equal(t, map[string]interface{}{"name": "0x1"}, resolved[0])
+	require.Equal(t, map[string]interface{}{"name": "0x2"}, resolved[1])
+}
+
+func TestBuildBatchVariables(t *testing.T) {
+	parentValues := []map[string]interface{}{{"id": "0x1"}, {"id": "0x2"}}
+	require.Equal(t, map[string]interface{}{"id": []interface{}{"0x1", "0x2"}},
+		buildBatchVariables("id", parentValues))
+}
+
+func TestScatterBatchResults(t *testing.T) {
+	parentValues := []map[string]interface{}{{"id": "0x1"}, {"id": "0x2"}}
+	results := []map[string]interface{}{{"id": "0x2", "name": "b"}}
+
+	scattered := scatterBatchResults("id", parentValues, results)
+	require.Nil(t, scattered[0])
+	require.Equal(t, map[string]interface{}{"id": "0x2", "name": "b"}, scattered[1])
+}
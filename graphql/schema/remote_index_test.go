@@ -0,0 +1,94 @@
+/*
+ * Copyright 2020 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package schema
+
+import (
+	"fmt"
+	"testing"
+)
+
+// syntheticIntrospection builds an IntrospectedSchema with numTypes types,
+// each with a handful of fields, to benchmark type/field lookup against a
+// schema of realistic size.
+func syntheticIntrospection(numTypes int) *IntrospectedSchema {
+	types := make([]Types, numTypes)
+	for i := 0; i < numTypes; i++ {
+		fields := make([]GqlField, 5)
+		for j := range fields {
+			fields[j] = GqlField{
+				Name: fmt.Sprintf("field%d", j),
+				Type: &GqlType{Kind: "SCALAR", Name: "String"},
+			}
+		}
+		types[i] = Types{
+			Kind:   "OBJECT",
+			Name:   fmt.Sprintf("Type%d", i),
+			Fields: fields,
+		}
+	}
+	schema := &IntrospectedSchema{}
+	schema.Data.Schema.Types = types
+	return schema
+}
+
+// linearScanTypeByName mirrors the pre-index lookup this benchmark compares
+// the indexed lookup against.
+func linearScanTypeByName(schema *IntrospectedSchema, name string) (*Types, bool) {
+	for i, typ := range schema.Data.Schema.Types {
+		if typ.Name == name {
+			return &schema.Data.Schema.Types[i], true
+		}
+	}
+	return nil, false
+}
+
+func BenchmarkTypeByName_LinearScan(b *testing.B) {
+	schema := syntheticIntrospection(5000)
+	lookupName := "Type4999"
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, ok := linearScanTypeByName(schema, lookupName); !ok {
+			b.Fatalf("type %s not found", lookupName)
+		}
+	}
+}
+
+func BenchmarkTypeByName_Indexed(b *testing.B) {
+	schema := syntheticIntrospection(5000)
+	lookupName := "Type4999"
+	schema.Index()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, ok := schema.typeByName(lookupName); !ok {
+			b.Fatalf("type %s not found", lookupName)
+		}
+	}
+}
+
+func BenchmarkFieldByName_Indexed(b *testing.B) {
+	schema := syntheticIntrospection(5000)
+	schema.Index()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, ok := schema.fieldByName("Type4999", "field4"); !ok {
+			b.Fatal("field not found")
+		}
+	}
+}
@@ -0,0 +1,244 @@
+/*
+ * Copyright 2020 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package schema
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/vektah/gqlparser/v2/ast"
+)
+
+// scalarValueKinds lists, for each built-in remote scalar, the literal
+// ast.ValueKinds that may be written for it. Scalars not listed here are
+// custom/unknown and accept any literal shape.
+var scalarValueKinds = map[string][]ast.ValueKind{
+	"Int":     {ast.IntValue},
+	"Float":   {ast.IntValue, ast.FloatValue},
+	"String":  {ast.StringValue, ast.BlockValue},
+	"ID":      {ast.StringValue, ast.BlockValue, ast.IntValue},
+	"Boolean": {ast.BooleanValue},
+}
+
+// validateSelectionSet recursively walks set, confirming that every field
+// (and, for unions/interfaces, every fragment) requested against
+// remoteTypeName actually exists on the remote schema. path is a dotted,
+// human readable location used to build precise errors, e.g.
+// "query.foo.bar.baz: field not present on remote type X".
+func validateSelectionSet(path, remoteTypeName string, set ast.SelectionSet,
+	remoteIntrospection *IntrospectedSchema) error {
+	remoteType := findRemoteType(remoteIntrospection, remoteTypeName)
+	if remoteType == nil {
+		return errors.Errorf("%s: remote type %s not present in remote schema", path, remoteTypeName)
+	}
+
+	for _, sel := range set {
+		switch f := sel.(type) {
+		case *ast.Field:
+			if f.Name == "__typename" {
+				continue
+			}
+			fieldPath := path + "." + f.Name
+			remoteField := findRemoteField(remoteIntrospection, remoteType, f.Name)
+			if remoteField == nil {
+				return errors.Errorf("%s: field not present on remote type %s", fieldPath,
+					remoteTypeName)
+			}
+			if err := validateArgumentValues(fieldPath, f, remoteField, remoteIntrospection); err != nil {
+				return err
+			}
+			if len(f.SelectionSet) == 0 {
+				continue
+			}
+			if err := validateSelectionSet(fieldPath, remoteField.Type.NamedType(), f.SelectionSet,
+				remoteIntrospection); err != nil {
+				return err
+			}
+		case *ast.InlineFragment:
+			if err := validateFragmentOnAbstractType(path, remoteType, f.TypeCondition,
+				f.SelectionSet, remoteIntrospection); err != nil {
+				return err
+			}
+		case *ast.FragmentSpread:
+			typeCondition := f.Definition.TypeCondition
+			if err := validateFragmentOnAbstractType(path, remoteType, typeCondition,
+				f.Definition.SelectionSet, remoteIntrospection); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// validateFragmentOnAbstractType checks that typeCondition is a valid
+// possible type of remoteType (a UNION or INTERFACE) before validating the
+// fragment's own selection set against that concrete type.
+func validateFragmentOnAbstractType(path string, remoteType *Types, typeCondition string,
+	set ast.SelectionSet, remoteIntrospection *IntrospectedSchema) error {
+	if remoteType.Kind == "UNION" || remoteType.Kind == "INTERFACE" {
+		found := false
+		for _, possible := range remoteType.PossibleTypes {
+			if possible.NamedType() == typeCondition {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return errors.Errorf("%s: type condition %s is not a possible type of remote type %s",
+				path, typeCondition, remoteType.Name)
+		}
+	}
+	return validateSelectionSet(path, typeCondition, set, remoteIntrospection)
+}
+
+// validateArgumentValues checks f's argument literals (variables are skipped)
+// against
+// the remote argument's type: scalar literal kind, enum value membership,
+// list shape and input object fields, recursively.
+func validateArgumentValues(path string, f *ast.Field, remoteField *GqlField,
+	remoteIntrospection *IntrospectedSchema) error {
+	remoteArgs, _ := getRemoteQueryArgsAsMap(remoteField)
+
+	for _, arg := range f.Arguments {
+		remoteArg, ok := remoteArgs[arg.Name]
+		if !ok {
+			continue
+		}
+		if err := validateLiteralValue(path+"."+arg.Name, arg.Value, remoteArg.Type,
+			remoteIntrospection); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateLiteralValue checks that value, a literal (or variable) given for a
+// remote arg/input-field of type argType, is shaped the way the remote
+// expects: the right scalar literal kind, a member of the enum, a list of
+// validly-typed elements, or an input object whose fields are all declared on
+// the remote input type and themselves validly typed.
+func validateLiteralValue(path string, value *ast.Value, argType *GqlType,
+	remoteIntrospection *IntrospectedSchema) error {
+	if value == nil || value.Kind == ast.Variable {
+		// Variables aren't checked here. Only the variables given to the root
+		// query/mutation's args are type-checked, by validateRemoteGraphql
+		// comparing declared type strings; variables on nested fields aren't.
+		return nil
+	}
+
+	if argType.Kind == "NON_NULL" {
+		if value.Kind == ast.NullValue {
+			return errors.Errorf("%s: null is not allowed for non-null remote arg type %s", path,
+				argType.String())
+		}
+		return validateLiteralValue(path, value, argType.OfType, remoteIntrospection)
+	}
+	if value.Kind == ast.NullValue {
+		return nil
+	}
+
+	switch argType.Kind {
+	case "LIST":
+		if value.Kind != ast.ListValue {
+			return errors.Errorf("%s: expected a list value for remote arg type %s, got %s", path,
+				argType.String(), value.Kind)
+		}
+		for i, child := range value.Children {
+			if err := validateLiteralValue(fmt.Sprintf("%s[%d]", path, i), child.Value, argType.OfType,
+				remoteIntrospection); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "ENUM":
+		if value.Kind != ast.EnumValue {
+			return errors.Errorf("%s: expected an enum value for remote type %s, got %s", path,
+				argType.Name, value.Kind)
+		}
+		enumType := findRemoteType(remoteIntrospection, argType.Name)
+		if enumType != nil && !enumValueExists(enumType, value.Raw) {
+			return errors.Errorf("%s: value %s is not a member of remote enum %s", path, value.Raw,
+				argType.Name)
+		}
+		return nil
+	case "INPUT_OBJECT":
+		if value.Kind != ast.ObjectValue {
+			return errors.Errorf("%s: expected an input object value for remote type %s, got %s",
+				path, argType.Name, value.Kind)
+		}
+		inputType := findRemoteType(remoteIntrospection, argType.Name)
+		if inputType == nil {
+			return nil
+		}
+		for _, child := range value.Children {
+			inputField, ok := remoteIntrospection.inputFieldByName(inputType.Name, child.Name)
+			if !ok {
+				return errors.Errorf("%s.%s: field not present on remote input type %s", path,
+					child.Name, inputType.Name)
+			}
+			if err := validateLiteralValue(path+"."+child.Name, child.Value, inputField.Type,
+				remoteIntrospection); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "SCALAR":
+		allowedKinds, known := scalarValueKinds[argType.Name]
+		if !known {
+			// Custom scalar: accept any literal shape.
+			return nil
+		}
+		for _, kind := range allowedKinds {
+			if value.Kind == kind {
+				return nil
+			}
+		}
+		return errors.Errorf("%s: value of kind %s is not valid for remote scalar type %s", path,
+			value.Kind, argType.Name)
+	default:
+		return nil
+	}
+}
+
+func enumValueExists(enumType *Types, value string) bool {
+	for _, ev := range enumType.EnumValues {
+		if ev.Name == value {
+			return true
+		}
+	}
+	return false
+}
+
+// findRemoteType returns the Types entry named name out of remoteIntrospection,
+// or nil if there isn't one.
+func findRemoteType(remoteIntrospection *IntrospectedSchema, name string) *Types {
+	typ, ok := remoteIntrospection.typeByName(name)
+	if !ok {
+		return nil
+	}
+	return typ
+}
+
+// findRemoteField returns the GqlField named name out of remoteType's Fields,
+// or nil if there isn't one.
+func findRemoteField(remoteIntrospection *IntrospectedSchema, remoteType *Types, name string) *GqlField {
+	field, ok := remoteIntrospection.fieldByName(remoteType.Name, name)
+	if !ok {
+		return nil
+	}
+	return field
+}